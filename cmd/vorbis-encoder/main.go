@@ -0,0 +1,85 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/jawher/mow.cli"
+	"github.com/xlab/closer"
+	"github.com/xlab/vorbis-go/encoder"
+)
+
+const samplesPerChannel = 2048
+
+var (
+	app     = cli.App("vorbis-encoder", "A tool implemented in Go that transcodes WAV files into OggVorbis, using this module's encoder.")
+	inFile  = app.StringArg("IN", "", "A local .wav file to encode.")
+	outFile = app.StringArg("OUT", "", "Destination .ogg file to write.")
+	quality = app.DoubleOpt("q quality", 0.6, "VBR quality, from 0.0 (lowest) to 1.0 (highest).")
+)
+
+func main() {
+	log.SetFlags(0)
+	app.Action = appRun
+	app.Run(os.Args)
+}
+
+func appRun() {
+	defer closer.Close()
+	closer.Bind(func() {
+		log.Println("Bye!")
+	})
+
+	in, err := os.Open(*inFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	closer.Bind(func() {
+		in.Close()
+	})
+
+	wav, err := readWavHeader(in)
+	if err != nil {
+		log.Fatalln("wav:", err)
+	}
+
+	out, err := os.Create(*outFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	closer.Bind(func() {
+		out.Close()
+	})
+
+	enc, err := encoder.New(out, encoder.Config{
+		Channels:   int32(wav.Channels),
+		SampleRate: int64(wav.SampleRate),
+		Mode:       encoder.ModeVBR,
+		Quality:    float32(*quality),
+		Vendor:     "xlab/vorbis-go encoder",
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	closer.Bind(func() {
+		if err := enc.Close(); err != nil {
+			log.Println("[WARN] encoder close error:", err)
+		}
+	})
+
+	log.Printf("Encoding %d channel, %d Hz WAV at quality %.2f...", wav.Channels, wav.SampleRate, *quality)
+
+	for {
+		frame, err := wav.ReadFrame(samplesPerChannel)
+		if len(frame) > 0 {
+			if err := enc.Write(frame); err != nil {
+				log.Fatalln(err)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	log.Println("Done.")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+const wavFormatIEEEFloat = 3
+
+// wavReader is a minimal reader for uncompressed PCM WAV files, just enough
+// to feed frames into the encoder.
+type wavReader struct {
+	r          io.Reader
+	Channels   int
+	SampleRate int
+	BitDepth   int
+	FormatTag  uint16
+}
+
+// readWavHeader parses the RIFF/WAVE header and positions r at the start of
+// the "data" chunk. Only PCM (format tag 1) and IEEE float (format tag 3)
+// WAV files are supported.
+func readWavHeader(r io.Reader) (*wavReader, error) {
+	var riffHdr [12]byte
+	if _, err := io.ReadFull(r, riffHdr[:]); err != nil {
+		return nil, err
+	}
+	if string(riffHdr[0:4]) != "RIFF" || string(riffHdr[8:12]) != "WAVE" {
+		return nil, errors.New("not a RIFF/WAVE file")
+	}
+
+	w := &wavReader{r: r}
+	var formatTag uint16
+
+	for {
+		var chunkHdr [8]byte
+		if _, err := io.ReadFull(r, chunkHdr[:]); err != nil {
+			return nil, errors.New("missing data chunk")
+		}
+		chunkID := string(chunkHdr[0:4])
+		chunkSize := binary.LittleEndian.Uint32(chunkHdr[4:8])
+
+		if chunkID == "fmt " {
+			if chunkSize < 16 {
+				return nil, errors.New("malformed fmt chunk: too short")
+			}
+			fmtBuf := make([]byte, chunkSize)
+			if _, err := io.ReadFull(r, fmtBuf); err != nil {
+				return nil, err
+			}
+			formatTag = binary.LittleEndian.Uint16(fmtBuf[0:2])
+			w.Channels = int(binary.LittleEndian.Uint16(fmtBuf[2:4]))
+			w.SampleRate = int(binary.LittleEndian.Uint32(fmtBuf[4:8]))
+			w.BitDepth = int(binary.LittleEndian.Uint16(fmtBuf[14:16]))
+			continue
+		}
+		if chunkID == "data" {
+			break
+		}
+		// skip chunk we don't care about, padded to an even offset
+		skip := int64(chunkSize)
+		if chunkSize%2 == 1 {
+			skip++
+		}
+		if _, err := io.CopyN(io.Discard, r, skip); err != nil {
+			return nil, err
+		}
+	}
+
+	if formatTag != 1 && formatTag != wavFormatIEEEFloat {
+		return nil, errors.New("only PCM or IEEE float WAV files are supported")
+	}
+	if w.Channels == 0 || w.SampleRate == 0 {
+		return nil, errors.New("malformed fmt chunk")
+	}
+	w.FormatTag = formatTag
+	return w, nil
+}
+
+// ReadFrame reads up to samplesPerChannel sample frames and converts them to
+// the [][]float32 shape expected by encoder.Encoder.Write.
+func (w *wavReader) ReadFrame(samplesPerChannel int) ([][]float32, error) {
+	bytesPerSample := w.BitDepth / 8
+	buf := make([]byte, samplesPerChannel*w.Channels*bytesPerSample)
+	n, err := io.ReadFull(w.r, buf)
+	if n == 0 {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return nil, err
+	}
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	frames := n / (w.Channels * bytesPerSample)
+	frame := make([][]float32, frames)
+	for i := range frame {
+		sample := make([]float32, w.Channels)
+		for ch := 0; ch < w.Channels; ch++ {
+			off := (i*w.Channels + ch) * bytesPerSample
+			sample[ch] = decodeSample(buf[off:off+bytesPerSample], w.BitDepth, w.FormatTag)
+		}
+		frame[i] = sample
+	}
+	return frame, err
+}
+
+func decodeSample(b []byte, bitDepth int, formatTag uint16) float32 {
+	if formatTag == wavFormatIEEEFloat && bitDepth == 32 {
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	}
+	switch bitDepth {
+	case 16:
+		v := int16(binary.LittleEndian.Uint16(b))
+		return float32(v) / 32768.0
+	case 32:
+		v := int32(binary.LittleEndian.Uint32(b))
+		return float32(v) / 2147483648.0
+	default:
+		return 0
+	}
+}
@@ -7,8 +7,6 @@ import (
 	"net/http"
 	"os"
 	"strings"
-	"sync"
-	"unsafe"
 
 	"github.com/apcera/termtables"
 	"github.com/jawher/mow.cli"
@@ -24,8 +22,11 @@ const (
 )
 
 var (
-	app = cli.App("vorbis-player", "A player implemented in Go that can read OggVorbis files and play using PortAudio.")
-	uri = app.StringArg("URI", "", "A local .ogg Vorbis file or an URL pointing to file.")
+	app      = cli.App("vorbis-player", "A player implemented in Go that can read OggVorbis files and play using PortAudio.")
+	uri      = app.StringArg("URI", "", "A local .ogg Vorbis file or an URL pointing to file.")
+	sinkKind = app.StringOpt("sink", "portaudio", "Output sink to use: portaudio, wav or null.")
+	outFile  = app.StringOpt("out", "", "Destination file, required when --sink=wav.")
+	rate     = app.IntOpt("rate", 0, "Force output at this sample rate (Hz), resampling if it differs from the file's native rate.")
 )
 
 func main() {
@@ -40,14 +41,17 @@ func appRun() {
 		log.Println("Bye!")
 	})
 
-	if err := portaudio.Initialize(); paError(err) {
-		log.Fatalln("PortAudio init error:", paErrorText(err))
-	}
-	closer.Bind(func() {
-		if err := portaudio.Terminate(); paError(err) {
-			log.Println("PortAudio term error:", paErrorText(err))
+	needsPortAudio := *sinkKind == "portaudio"
+	if needsPortAudio {
+		if err := portaudio.Initialize(); paError(err) {
+			log.Fatalln("PortAudio init error:", paErrorText(err))
 		}
-	})
+		closer.Bind(func() {
+			if err := portaudio.Terminate(); paError(err) {
+				log.Println("PortAudio term error:", paErrorText(err))
+			}
+		})
+	}
 
 	var input io.Reader
 	if strings.HasPrefix(*uri, "http://") || strings.HasPrefix(*uri, "https://") {
@@ -79,38 +83,49 @@ func appRun() {
 	info := dec.Info()
 	log.Println(fileInfoTable(info))
 
-	dec.SetErrorHandler(func(err error) {
-		log.Println("[WARN]", err)
-	})
-	go func() {
-		dec.Decode()
-		dec.Close()
-	}()
+	sink, err := newSink(*sinkKind, *outFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-	var wg sync.WaitGroup
-	var stream *portaudio.Stream
-	callback := paCallback(&wg, int(info.Channels), dec.SamplesOut())
-	if err := portaudio.OpenDefaultStream(&stream, 0, int32(info.Channels), sampleFormat, info.SampleRate,
-		samplesPerChannel, callback, nil); paError(err) {
-		log.Fatalln("PortAudio error:", paErrorText(err))
+	outRate := info.SampleRate
+	if *rate > 0 {
+		outRate = float64(*rate)
+	}
+	if err := sink.Open(int(info.Channels), outRate, samplesPerChannel); err != nil {
+		log.Fatalln(err)
 	}
 	closer.Bind(func() {
-		if err := portaudio.CloseStream(stream); paError(err) {
-			log.Println("[WARN] PortAudio error:", paErrorText(err))
+		if err := sink.Close(); err != nil {
+			log.Println("[WARN] sink close error:", err)
 		}
 	})
 
-	if err := portaudio.StartStream(stream); paError(err) {
-		log.Fatalln("PortAudio error:", paErrorText(err))
+	var resamp *resampler
+	if *rate > 0 {
+		resamp = newResampler(int(info.Channels), info.SampleRate, outRate)
 	}
-	closer.Bind(func() {
-		if err := portaudio.StopStream(stream); paError(err) {
-			log.Fatalln("[WARN] PortAudio error:", paErrorText(err))
-		}
+
+	dec.SetErrorHandler(func(err error) {
+		log.Println("[WARN]", err)
 	})
+	go func() {
+		dec.Decode()
+		dec.Close()
+	}()
 
 	log.Println("Playing...")
-	wg.Wait()
+	for frame := range dec.SamplesOut() {
+		if resamp != nil {
+			frame = resamp.Process(frame)
+			if len(frame) == 0 {
+				continue
+			}
+		}
+		if err := sink.Write(frame); err != nil {
+			log.Fatalln(err)
+		}
+	}
 }
 
 func fileInfoTable(info decoder.Info) string {
@@ -130,38 +145,3 @@ func fileInfoTable(info decoder.Info) string {
 	table.AddRow("Encoded by", info.Vendor)
 	return table.Render()
 }
-
-func paCallback(wg *sync.WaitGroup, channels int, samples <-chan [][]float32) portaudio.StreamCallback {
-	wg.Add(1)
-	return func(_ unsafe.Pointer, output unsafe.Pointer, sampleCount uint,
-		_ *portaudio.StreamCallbackTimeInfo, _ portaudio.StreamCallbackFlags, _ unsafe.Pointer) int32 {
-
-		const (
-			statusContinue = int32(portaudio.PaContinue)
-			statusComplete = int32(portaudio.PaComplete)
-		)
-
-		frame, ok := <-samples
-		if !ok {
-			wg.Done()
-			return statusComplete
-		}
-		if len(frame) > int(sampleCount) {
-			frame = frame[:sampleCount]
-		}
-
-		var idx int
-		out := (*(*[1 << 32]float32)(unsafe.Pointer(output)))[:int(sampleCount)*channels]
-		for _, sample := range frame {
-			if len(sample) > channels {
-				sample = sample[:channels]
-			}
-			for i := range sample {
-				out[idx] = sample[i]
-				idx++
-			}
-		}
-
-		return statusContinue
-	}
-}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/xlab/portaudio-go/portaudio"
+)
+
+const sinkFrameBuffer = 8
+
+// portAudioSink plays decoded frames through the default PortAudio output
+// device. It is the sink used by the player historically, now behind the
+// AudioSink interface so it can be swapped for file or null output.
+type portAudioSink struct {
+	stream   *portaudio.Stream
+	wg       sync.WaitGroup
+	frames   chan [][]float32
+	channels int
+}
+
+func newPortAudioSink() *portAudioSink {
+	return &portAudioSink{
+		frames: make(chan [][]float32, sinkFrameBuffer),
+	}
+}
+
+func (s *portAudioSink) Open(channels int, sampleRate float64, samplesPerChannel int) error {
+	s.channels = channels
+	callback := s.callback(channels)
+	if err := portaudio.OpenDefaultStream(&s.stream, 0, int32(channels), sampleFormat, sampleRate,
+		samplesPerChannel, callback, nil); paError(err) {
+		return fmt.Errorf("PortAudio error: %s", paErrorText(err))
+	}
+	if err := portaudio.StartStream(s.stream); paError(err) {
+		return fmt.Errorf("PortAudio error: %s", paErrorText(err))
+	}
+	return nil
+}
+
+func (s *portAudioSink) Write(frame [][]float32) error {
+	s.frames <- frame
+	return nil
+}
+
+func (s *portAudioSink) Close() error {
+	close(s.frames)
+	s.wg.Wait()
+	if err := portaudio.StopStream(s.stream); paError(err) {
+		return fmt.Errorf("PortAudio error: %s", paErrorText(err))
+	}
+	if err := portaudio.CloseStream(s.stream); paError(err) {
+		return fmt.Errorf("PortAudio error: %s", paErrorText(err))
+	}
+	return nil
+}
+
+func (s *portAudioSink) callback(channels int) portaudio.StreamCallback {
+	s.wg.Add(1)
+	return func(_ unsafe.Pointer, output unsafe.Pointer, sampleCount uint,
+		_ *portaudio.StreamCallbackTimeInfo, _ portaudio.StreamCallbackFlags, _ unsafe.Pointer) int32 {
+
+		const (
+			statusContinue = int32(portaudio.PaContinue)
+			statusComplete = int32(portaudio.PaComplete)
+		)
+
+		frame, ok := <-s.frames
+		if !ok {
+			s.wg.Done()
+			return statusComplete
+		}
+		if len(frame) > int(sampleCount) {
+			frame = frame[:sampleCount]
+		}
+
+		var idx int
+		out := (*(*[1 << 32]float32)(unsafe.Pointer(output)))[:int(sampleCount)*channels]
+		for _, sample := range frame {
+			if len(sample) > channels {
+				sample = sample[:channels]
+			}
+			for i := range sample {
+				out[idx] = sample[i]
+				idx++
+			}
+		}
+
+		return statusContinue
+	}
+}
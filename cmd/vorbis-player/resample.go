@@ -0,0 +1,49 @@
+package main
+
+// resampler converts frames produced at an input sample rate into frames at
+// a target output rate using linear interpolation between samples. It is
+// deliberately simple: good enough to feed a device that does not support a
+// file's native rate, without pulling in an external DSP dependency.
+type resampler struct {
+	channels int
+	ratio    float64 // inRate / outRate
+	pos      float64 // fractional read position between prev and the next input sample
+	prev     []float32
+	havePrev bool
+}
+
+// newResampler returns a resampler, or nil if no resampling is required.
+func newResampler(channels int, inRate, outRate float64) *resampler {
+	if inRate == outRate || outRate <= 0 {
+		return nil
+	}
+	return &resampler{
+		channels: channels,
+		ratio:    inRate / outRate,
+	}
+}
+
+// Process resamples one input frame, returning zero or more output samples
+// depending on the input/output rate ratio.
+func (r *resampler) Process(frame [][]float32) [][]float32 {
+	out := make([][]float32, 0, len(frame))
+
+	for _, cur := range frame {
+		if !r.havePrev {
+			r.prev = append([]float32(nil), cur...)
+			r.havePrev = true
+			continue
+		}
+		for r.pos < 1 {
+			sample := make([]float32, r.channels)
+			for ch := 0; ch < r.channels; ch++ {
+				sample[ch] = r.prev[ch] + (cur[ch]-r.prev[ch])*float32(r.pos)
+			}
+			out = append(out, sample)
+			r.pos += r.ratio
+		}
+		r.pos -= 1
+		r.prev = append(r.prev[:0], cur...)
+	}
+	return out
+}
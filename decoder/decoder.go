@@ -62,6 +62,20 @@ type Decoder struct {
 	stopChan chan struct{}
 	closed   bool
 	onError  func(err error)
+
+	// pull holds the extra state used by the pull-based ReadSamples/DecodePacket
+	// API; that API shares dspState/block with Decode.
+	pull pullState
+
+	// pushInited is set when Decode is first called, and is
+	// never cleared, so a Decoder commits permanently to either the push
+	// (Decode) or pull (ReadSamples/DecodePacket) API on first use.
+	pushInited bool
+
+	// packetsIn, when non-nil, indicates the Decoder was built with
+	// NewFromPackets and Decode should read raw packets from this channel
+	// instead of demuxing them from an Ogg bitstream.
+	packetsIn <-chan []byte
 }
 
 // Info represents basic information about the audio in a Vorbis bitstream.
@@ -142,6 +156,12 @@ func (d *Decoder) Close() {
 }
 
 func (d *Decoder) decoderStateCleanup() {
+	if d.pull.inited {
+		vorbis.BlockClear(&d.block)
+		vorbis.DspClear(&d.dspState)
+		d.pull.inited = false
+	}
+
 	vorbis.OggStreamClear(&d.streamState)
 	d.streamState.Free()
 
@@ -249,6 +269,10 @@ func (d *Decoder) Decode() error {
 	if d.closed {
 		return errors.New("decoder: decoder has already been closed")
 	}
+	if d.pull.inited {
+		return errors.New("decoder: cannot call Decode after ReadSamples/DecodePacket have been used on this Decoder")
+	}
+	d.pushInited = true
 
 	if ret := vorbis.SynthesisInit(&d.dspState, &d.info); ret < 0 {
 		err := errors.New("vorbis: error during playback initialization")
@@ -260,6 +284,10 @@ func (d *Decoder) Decode() error {
 	vorbis.BlockInit(&d.dspState, &d.block)
 	defer vorbis.BlockClear(&d.block)
 
+	if d.packetsIn != nil {
+		return d.decodePacketsFromChannel()
+	}
+
 	frame := make([][]float32, 0, d.samplesPerChannel)
 	pcm := [][][]float32{
 		make([][]float32, d.info.Channels),
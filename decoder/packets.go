@@ -0,0 +1,157 @@
+package decoder
+
+import (
+	"errors"
+
+	"github.com/xlab/vorbis-go/vorbis"
+)
+
+// NewFromPackets creates a Decoder that bypasses the Ogg framing layer
+// entirely, for Vorbis audio demuxed from a non-Ogg container such as
+// Matroska/WebM, MP4 or RTP, where the three setup packets arrive out of band
+// as a codec-private blob and audio packets arrive as discrete units.
+//
+// headers must contain exactly the three Vorbis header packets, in order:
+// identification, comment and setup. packets supplies audio packets as they
+// are demuxed; closing it signals end-of-stream to Decode.
+func NewFromPackets(headers [][]byte, packets <-chan []byte, samplesPerChannel int) (*Decoder, error) {
+	if len(headers) != 3 {
+		return nil, errors.New("decoder: NewFromPackets requires exactly 3 header packets (identification, comment, setup)")
+	}
+
+	d := &Decoder{
+		samplesPerChannel: samplesPerChannel,
+
+		pcmOut:    make(chan [][]float32, OUT_BUFFER_SIZE),
+		stopChan:  make(chan struct{}),
+		packetsIn: packets,
+	}
+
+	vorbis.InfoInit(&d.info)
+	vorbis.CommentInit(&d.comment)
+
+	for i, raw := range headers {
+		pkt := vorbis.OggPacket{
+			Packet:   raw,
+			Bytes:    int64(len(raw)),
+			BOS:      boolToInt64(i == 0),
+			Packetno: int64(i),
+		}
+		if ret := vorbis.SynthesisHeaderin(&d.info, &d.comment, &pkt); ret < 0 {
+			d.decoderStateCleanup()
+			return nil, errors.New("vorbis: unable to decode a supplied Vorbis header packet")
+		}
+	}
+
+	d.info.Deref()
+	d.comment.Deref()
+	d.comment.UserComments = make([][]byte, d.comment.Comments)
+	d.comment.Deref()
+	return d, nil
+}
+
+func boolToInt64(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// decodePacketsFromChannel is Decode's decode loop when the Decoder was built
+// with NewFromPackets: it pulls raw Vorbis packets directly off packetsIn
+// instead of demuxing them from an Ogg bitstream.
+func (d *Decoder) decodePacketsFromChannel() error {
+	frame := make([][]float32, 0, d.samplesPerChannel)
+	pcm := [][][]float32{
+		make([][]float32, d.info.Channels),
+	}
+	defer func() {
+		if len(frame) > 0 {
+			d.sendFrame(frame)
+		}
+	}()
+
+	var packetno int64
+	for {
+		select {
+		case <-d.stopChan:
+			return nil
+		case raw, ok := <-d.packetsIn:
+			if !ok {
+				return nil
+			}
+			pkt := vorbis.OggPacket{
+				Packet:   raw,
+				Bytes:    int64(len(raw)),
+				Packetno: packetno,
+			}
+			packetno++
+
+			if vorbis.Synthesis(&d.block, &pkt) != 0 {
+				continue
+			}
+			vorbis.SynthesisBlockin(&d.dspState, &d.block)
+
+			for samples := vorbis.SynthesisPcmout(&d.dspState, pcm); samples > 0; samples = vorbis.SynthesisPcmout(&d.dspState, pcm) {
+				space := int32(d.samplesPerChannel - len(frame))
+				if samples > space {
+					samples = space
+				}
+				for i := 0; i < int(samples); i++ {
+					sample := make([]float32, d.info.Channels)
+					for ch := 0; ch < int(d.info.Channels); ch++ {
+						sample[ch] = pcm[0][ch][:samples][i]
+					}
+					frame = append(frame, sample)
+				}
+				if len(frame) == d.samplesPerChannel {
+					d.sendFrame(frame)
+					frame = make([][]float32, 0, d.samplesPerChannel)
+				}
+				vorbis.SynthesisRead(&d.dspState, samples)
+			}
+		}
+	}
+}
+
+// ParseXiphLacedHeaders splits a Matroska/WebM Vorbis CodecPrivate blob into
+// its three Vorbis header packets. The layout is: one byte holding the packet
+// count minus one, followed by that many Xiph-style lacing lengths (each a
+// run of 0xFF bytes terminated by a remainder byte), followed by the
+// concatenated packets; the final packet's length is whatever bytes remain.
+func ParseXiphLacedHeaders(codecPrivate []byte) ([][]byte, error) {
+	if len(codecPrivate) < 1 {
+		return nil, errors.New("decoder: codec private data is empty")
+	}
+
+	count := int(codecPrivate[0]) + 1
+	offset := 1
+	lengths := make([]int, count-1)
+
+	for i := range lengths {
+		length := 0
+		for {
+			if offset >= len(codecPrivate) {
+				return nil, errors.New("decoder: truncated xiph lacing in codec private data")
+			}
+			b := codecPrivate[offset]
+			offset++
+			length += int(b)
+			if b != 0xFF {
+				break
+			}
+		}
+		lengths[i] = length
+	}
+
+	packets := make([][]byte, count)
+	for i, length := range lengths {
+		if offset+length > len(codecPrivate) {
+			return nil, errors.New("decoder: truncated xiph-laced packet in codec private data")
+		}
+		packets[i] = codecPrivate[offset : offset+length]
+		offset += length
+	}
+	packets[count-1] = codecPrivate[offset:]
+	return packets, nil
+}
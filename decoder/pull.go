@@ -0,0 +1,194 @@
+package decoder
+
+import (
+	"errors"
+	"io"
+
+	"github.com/xlab/vorbis-go/vorbis"
+)
+
+// pullState holds the buffers and init flag used by the pull-based API
+// (ReadSamples, DecodePacket). It shares the Decoder's dspState/block with
+// Decode; the inited and pushInited flags keep the two APIs from being
+// interleaved on the same Decoder.
+type pullState struct {
+	inited  bool
+	pcm     [][][]float32 // scratch planar buffer handed to vorbis.SynthesisPcmout
+	pending [][]float32   // planar samples decoded but not yet delivered to the caller
+}
+
+// ReadSamples decodes on demand into dst, a preallocated planar buffer shaped
+// [channels][samplesPerChannel]. It returns the number of samples per channel
+// written into dst, which may be less than len(dst[0]) only at end of stream.
+// Unlike Decode/SamplesOut, ReadSamples performs no goroutine or channel
+// indirection and reuses its internal scratch buffers across calls, making it
+// safe to call directly from a real-time callback such as PortAudio's.
+func (d *Decoder) ReadSamples(dst [][]float32) (n int, err error) {
+	d.Lock()
+	defer d.Unlock()
+	if d.closed {
+		return 0, errors.New("decoder: decoder has already been closed")
+	}
+	if err := d.ensurePullState(); err != nil {
+		return 0, err
+	}
+	if len(dst) == 0 {
+		return 0, nil
+	}
+	want := len(dst[0])
+
+	for n < want {
+		if len(d.pull.pending) == 0 || len(d.pull.pending[0]) == 0 {
+			more, err := d.decodeNextBlock()
+			if err != nil {
+				return n, err
+			}
+			if more == 0 {
+				continue
+			}
+		}
+
+		take := len(d.pull.pending[0])
+		if take > want-n {
+			take = want - n
+		}
+		for ch := range dst {
+			copy(dst[ch][n:n+take], d.pull.pending[ch][:take])
+		}
+		for ch := range d.pull.pending {
+			d.pull.pending[ch] = d.pull.pending[ch][take:]
+		}
+		n += take
+	}
+	return n, nil
+}
+
+// ReadSamplesInterleaved is like ReadSamples but writes channel-interleaved
+// samples into a single flat buffer, e.g. for handing straight to an audio
+// API that expects interleaved PCM.
+func (d *Decoder) ReadSamplesInterleaved(dst []float32, channels int) (n int, err error) {
+	planar := make([][]float32, channels)
+	want := len(dst) / channels
+	for ch := range planar {
+		planar[ch] = make([]float32, want)
+	}
+
+	n, err = d.ReadSamples(planar)
+	for i := 0; i < n; i++ {
+		for ch := 0; ch < channels; ch++ {
+			dst[i*channels+ch] = planar[ch][i]
+		}
+	}
+	return n, err
+}
+
+// DecodePacket decodes exactly one Vorbis packet's worth of samples and
+// returns them as a planar [channels][]float32 buffer, or io.EOF once the
+// logical bitstream is exhausted. If a previous ReadSamples call left part of
+// a packet's samples undelivered, that remainder is returned instead. It uses
+// the same per-packet decode step as ReadSamples, exposed for callers that
+// want to build their own scheduling around the
+// underlying vorbis_synthesis/vorbis_synthesis_blockin/vorbis_synthesis_pcmout
+// loop directly.
+func (d *Decoder) DecodePacket() ([][]float32, error) {
+	d.Lock()
+	defer d.Unlock()
+	if d.closed {
+		return nil, errors.New("decoder: decoder has already been closed")
+	}
+	if err := d.ensurePullState(); err != nil {
+		return nil, err
+	}
+
+	if len(d.pull.pending) > 0 && len(d.pull.pending[0]) > 0 {
+		out := d.pull.pending
+		d.pull.pending = nil
+		return out, nil
+	}
+
+	for {
+		n, err := d.decodeNextBlock()
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			out := d.pull.pending
+			d.pull.pending = nil
+			return out, nil
+		}
+	}
+}
+
+func (d *Decoder) ensurePullState() error {
+	if d.pull.inited {
+		return nil
+	}
+	if d.pushInited {
+		return errors.New("decoder: cannot call ReadSamples/DecodePacket after Decode has been used on this Decoder")
+	}
+	if ret := vorbis.SynthesisInit(&d.dspState, &d.info); ret < 0 {
+		return errors.New("vorbis: error during playback initialization")
+	}
+	vorbis.BlockInit(&d.dspState, &d.block)
+
+	d.pull.pcm = [][][]float32{
+		make([][]float32, d.info.Channels),
+	}
+	d.pull.inited = true
+	return nil
+}
+
+// decodeNextBlock pulls Ogg/Vorbis data until at least one Vorbis block has
+// produced samples, filling d.pull.pending (planar, per-channel slices) and
+// returning how many samples per channel are now pending. It returns io.EOF
+// once the logical bitstream is exhausted with no further samples available.
+func (d *Decoder) decodeNextBlock() (int, error) {
+	for {
+		var eos bool
+		ret := vorbis.OggSyncPageout(&d.syncState, &d.page)
+		if ret < 0 {
+			continue // bytes skipped while resyncing, try again
+		}
+		if ret == 0 {
+			if n, err := d.readChunk(d.input); err == nil || n > 0 {
+				continue
+			}
+			// no more input: drain packets still buffered in streamState, then stop
+			eos = true
+		} else {
+			vorbis.OggStreamPagein(&d.streamState, &d.page)
+			eos = vorbis.OggPageEos(&d.page) == 1
+		}
+
+		for {
+			pret := vorbis.OggStreamPacketout(&d.streamState, &d.packet)
+			if pret < 0 {
+				continue // skip packet
+			}
+			if pret == 0 {
+				break // go get the next page
+			}
+			if vorbis.Synthesis(&d.block, &d.packet) != 0 {
+				continue
+			}
+			vorbis.SynthesisBlockin(&d.dspState, &d.block)
+
+			samples := vorbis.SynthesisPcmout(&d.dspState, d.pull.pcm)
+			if samples <= 0 {
+				continue
+			}
+
+			pending := make([][]float32, d.info.Channels)
+			for ch := int32(0); ch < d.info.Channels; ch++ {
+				pending[ch] = append([]float32(nil), d.pull.pcm[0][ch][:samples]...)
+			}
+			vorbis.SynthesisRead(&d.dspState, samples)
+			d.pull.pending = pending
+			return int(samples), nil
+		}
+
+		if eos {
+			return 0, io.EOF
+		}
+	}
+}
@@ -0,0 +1,483 @@
+package decoder
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/xlab/vorbis-go/vorbis"
+)
+
+// BISECT_CHUNK_SIZE is the amount of data read from the source on each probe
+// while bisecting for a target sample during Seek.
+const BISECT_CHUNK_SIZE = 4096
+
+// link holds the per-chain state of a chained bitstream: its own Vorbis info
+// plus the byte and sample-time ranges it occupies within the physical file.
+// Since each chained link's own granulepos numbering restarts near 0, pcmStart
+// and pcmEnd are both expressed as absolute sample indices over the whole,
+// possibly chained, file so callers never need to know link-local offsets.
+type link struct {
+	serialno   int32
+	info       Info
+	byteOffset int64 // offset of this link's first header page
+	dataOffset int64 // offset of this link's first audio (non-header) page
+	byteEnd    int64 // offset just past this link's last page
+	pcmStart   int64 // absolute sample index of the link's first sample
+	pcmEnd     int64 // absolute sample index just past the link's last sample
+}
+
+// SeekableDecoder is a random-access OggVorbis decoder in the spirit of
+// libvorbisfile's OggVorbis_File: in addition to everything Decoder offers,
+// it supports seeking to an arbitrary sample or time offset and reports the
+// total length of the (possibly chained) bitstream up front.
+type SeekableDecoder struct {
+	*Decoder
+
+	source   io.ReadSeeker
+	fileSize int64
+
+	links     []link
+	linkIndex int
+	totalPcm  int64
+}
+
+// NewSeekable creates a new OggVorbis decoder that supports random access
+// over r. The source is scanned once, up front, to locate every chained
+// logical bitstream and the total sample count.
+func NewSeekable(r io.ReadSeeker, samplesPerChannel int) (*SeekableDecoder, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	dec, err := New(r, samplesPerChannel)
+	if err != nil {
+		return nil, err
+	}
+
+	// scanLinks parses the whole file through its own independent sync state,
+	// driving r all the way to EOF. Record where New's header read left r so
+	// sequential playback can resume exactly there once the scan is done.
+	resumeOffset, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		dec.Close()
+		return nil, err
+	}
+
+	sd := &SeekableDecoder{
+		Decoder:  dec,
+		source:   r,
+		fileSize: size,
+	}
+	if err := sd.scanLinks(); err != nil {
+		dec.Close()
+		return nil, err
+	}
+
+	if _, err := r.Seek(resumeOffset, io.SeekStart); err != nil {
+		dec.Close()
+		return nil, err
+	}
+	return sd, nil
+}
+
+// scanLinks walks the entire physical bitstream once, front to back, to
+// record every logical (possibly chained) bitstream it contains: the byte
+// range each link occupies, its decoded Info, and its sample span. Each link
+// begins at a BOS page, whose byte offset is recorded precisely so that a
+// later seek into that link can reinitialize headers at a known-good
+// position instead of guessing from wherever a bisection probe landed.
+func (sd *SeekableDecoder) scanLinks() error {
+	if _, err := sd.source.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	var syncState vorbis.OggSyncState
+	var page vorbis.OggPage
+	vorbis.OggSyncInit(&syncState)
+	defer func() {
+		vorbis.OggSyncDestroy(&syncState)
+		syncState.Free()
+		page.Free()
+	}()
+
+	var links []link
+	var pagePos int64
+	var pcmBase int64 // running absolute-sample total of every link fully scanned so far
+
+	for {
+		ret := vorbis.OggSyncPageout(&syncState, &page)
+		if ret == 0 {
+			buf := vorbis.OggSyncBuffer(&syncState, BISECT_CHUNK_SIZE)
+			n, rerr := io.ReadFull(sd.source, buf[:BISECT_CHUNK_SIZE])
+			vorbis.OggSyncWrote(&syncState, n)
+			if n == 0 {
+				break
+			}
+			if rerr != nil && rerr != io.EOF && rerr != io.ErrUnexpectedEOF {
+				return rerr
+			}
+			continue
+		}
+		if ret < 0 {
+			continue // bytes skipped while resyncing; pagePos bookkeeping below only advances on ret == 1
+		}
+
+		serialno := vorbis.OggPageSerialno(&page)
+		pageLen := int64(page.HeaderLen + page.BodyLen)
+
+		if vorbis.OggPageBos(&page) == 1 {
+			if n := len(links); n > 0 {
+				last := &links[n-1]
+				last.byteEnd = pagePos
+				pcmBase += last.pcmEnd - last.pcmStart
+			}
+			// readLinkHeaders consumes further pages off syncState to collect
+			// all three header packets, which may span more than this single
+			// BOS page, so it is the one that advances pagePos from here on.
+			newPagePos, info, err := sd.readLinkHeaders(&syncState, &page, pagePos+pageLen)
+			if err != nil {
+				return err
+			}
+			links = append(links, link{
+				serialno:   serialno,
+				info:       info,
+				byteOffset: pagePos,
+				dataOffset: newPagePos,
+				pcmStart:   pcmBase,
+				pcmEnd:     pcmBase,
+			})
+			pagePos = newPagePos
+			continue
+		} else if n := len(links); n > 0 && links[n-1].serialno == serialno {
+			if g := vorbis.OggPageGranulepos(&page); g >= 0 {
+				links[n-1].pcmEnd = links[n-1].pcmStart + g
+			}
+		}
+
+		pagePos += pageLen
+	}
+
+	if len(links) == 0 {
+		return errors.New("vorbis: no logical bitstreams found while scanning for chain boundaries")
+	}
+	links[len(links)-1].byteEnd = sd.fileSize
+
+	sd.links = links
+	sd.totalPcm = links[len(links)-1].pcmEnd
+	return nil
+}
+
+// readLinkHeaders decodes the three Vorbis header packets for the logical
+// bitstream starting at the BOS page already pulled into page, using a
+// private stream state so it doesn't disturb the Decoder's own
+// streamState/info/comment. pagePos is the byte offset just
+// past the already-consumed BOS page; readLinkHeaders accounts for every
+// further page it pulls off syncState while collecting the remaining two
+// header packets and returns the offset just past the last of them, i.e.
+// where this link's first audio page begins.
+func (sd *SeekableDecoder) readLinkHeaders(syncState *vorbis.OggSyncState, page *vorbis.OggPage, pagePos int64) (int64, Info, error) {
+	var streamState vorbis.OggStreamState
+	var packet vorbis.OggPacket
+	var info vorbis.Info
+	var comment vorbis.Comment
+	vorbis.OggStreamInit(&streamState, vorbis.OggPageSerialno(page))
+	vorbis.InfoInit(&info)
+	vorbis.CommentInit(&comment)
+	defer func() {
+		vorbis.OggStreamClear(&streamState)
+		streamState.Free()
+		vorbis.CommentClear(&comment)
+		comment.Free()
+		vorbis.InfoClear(&info)
+		info.Free()
+		packet.Free()
+	}()
+
+	if ret := vorbis.OggStreamPagein(&streamState, page); ret < 0 {
+		return 0, Info{}, errors.New("vorbis: the supplied page does not belong this Vorbis stream")
+	}
+	if ret := vorbis.OggStreamPacketout(&streamState, &packet); ret != 1 {
+		return 0, Info{}, errors.New("vorbis: unable to fetch initial Vorbis packet from the first page")
+	}
+	if ret := vorbis.SynthesisHeaderin(&info, &comment, &packet); ret < 0 {
+		return 0, Info{}, errors.New("vorbis: unable to decode the initial Vorbis header")
+	}
+
+	var headersRead int
+forPage:
+	for headersRead < 2 {
+		if res := vorbis.OggSyncPageout(syncState, page); res < 0 {
+			continue forPage
+		} else if res == 0 {
+			buf := vorbis.OggSyncBuffer(syncState, BISECT_CHUNK_SIZE)
+			n, _ := io.ReadFull(sd.source, buf[:BISECT_CHUNK_SIZE])
+			vorbis.OggSyncWrote(syncState, n)
+			if n == 0 {
+				return 0, Info{}, errors.New("vorbis: got EOF while reading Vorbis headers")
+			}
+			continue forPage
+		}
+		pagePos += int64(page.HeaderLen + page.BodyLen)
+		vorbis.OggStreamPagein(&streamState, page)
+		for headersRead < 2 {
+			if ret := vorbis.OggStreamPacketout(&streamState, &packet); ret < 0 {
+				return 0, Info{}, errors.New("vorbis: data is missing near the secondary Vorbis header")
+			} else if ret == 0 {
+				continue forPage
+			}
+			if ret := vorbis.SynthesisHeaderin(&info, &comment, &packet); ret < 0 {
+				return 0, Info{}, errors.New("vorbis: unable to read the secondary Vorbis header")
+			}
+			headersRead++
+		}
+	}
+
+	info.Deref()
+	comment.Deref()
+	comment.UserComments = make([][]byte, comment.Comments)
+	comment.Deref()
+
+	out := Info{
+		Channels:   info.Channels,
+		SampleRate: float64(info.Rate),
+		Vendor:     toString(comment.Vendor, 256),
+	}
+	lengths := comment.CommentLengths[:comment.Comments]
+	userComments := comment.UserComments[:comment.Comments]
+	for i, text := range userComments {
+		out.Comments = append(out.Comments, string(text[:lengths[i]]))
+	}
+	return pagePos, out, nil
+}
+
+// TotalSamples returns the total number of samples (per channel) across the
+// whole, possibly chained, bitstream.
+func (sd *SeekableDecoder) TotalSamples() int64 {
+	return sd.totalPcm
+}
+
+// Duration returns the total playback duration of the bitstream.
+func (sd *SeekableDecoder) Duration() time.Duration {
+	rate := sd.info.Rate
+	if rate == 0 {
+		return 0
+	}
+	seconds := float64(sd.totalPcm) / float64(rate)
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// Bitrate returns the nominal, upper and lower bitrate bounds declared by the
+// bitstream's identification header. A bound of zero means the encoder did
+// not declare that bound.
+func (sd *SeekableDecoder) Bitrate() (nominal, upper, lower int32) {
+	return sd.info.BitrateNominal, sd.info.BitrateUpper, sd.info.BitrateLower
+}
+
+// LinkInfo returns the Info for the link'th logical bitstream in a chained
+// file (link 0 for an unchained file).
+func (sd *SeekableDecoder) LinkInfo(link int) Info {
+	if link < 0 || link >= len(sd.links) {
+		return Info{}
+	}
+	return sd.links[link].info
+}
+
+// SeekTime seeks to the given playback position.
+func (sd *SeekableDecoder) SeekTime(d time.Duration) error {
+	rate := sd.info.Rate
+	if rate == 0 {
+		return errors.New("vorbis: stream has no sample rate")
+	}
+	sample := int64(d.Seconds() * float64(rate))
+	return sd.Seek(sample)
+}
+
+// Seek repositions the decoder so that the next samples read from
+// SamplesOut() start at the given absolute sample offset, by bisecting over
+// file offsets to locate the Ogg page containing it (switching links using
+// the chain boundaries found by scanLinks if the probe crosses into a
+// different one), then discarding samples up to the exact target.
+func (sd *SeekableDecoder) Seek(sample int64) error {
+	sd.Lock()
+	defer sd.Unlock()
+	if sd.closed {
+		return errors.New("decoder: decoder has already been closed")
+	}
+	if sample < 0 || sample > sd.totalPcm {
+		return errors.New("decoder: seek target out of range")
+	}
+
+	// Pick the link whose absolute sample range contains the target before
+	// bisecting, rather than assuming it falls in whatever link is current.
+	idx := len(sd.links) - 1
+	for i, l := range sd.links {
+		if sample < l.pcmEnd {
+			idx = i
+			break
+		}
+	}
+	if idx != sd.linkIndex {
+		// Load the target link's headers so its pages are accepted.
+		if err := sd.switchToLink(sd.links[idx].serialno); err != nil {
+			return err
+		}
+	}
+	sd.linkIndex = idx
+	lnk := sd.links[idx]
+	localTarget := sample - lnk.pcmStart
+
+	lo, hi := lnk.byteOffset, lnk.byteEnd
+
+	var pageOffset int64
+	var pageGranule int64
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		offset, granule, serial, err := sd.findPageAt(mid)
+		if err != nil {
+			return err
+		}
+		if serial != lnk.serialno {
+			// The bisection probe landed in a different chained link than
+			// expected; switch to it using its known header offset from the
+			// up-front scan, rather than guessing at headers from whatever
+			// audio packet the probe happened to land on.
+			if err := sd.switchToLink(serial); err != nil {
+				return err
+			}
+			lnk = sd.links[sd.linkIndex]
+			localTarget = sample - lnk.pcmStart
+			lo, hi = lnk.byteOffset, lnk.byteEnd
+			continue
+		}
+		if granule < localTarget {
+			lo = offset + 1
+			pageOffset, pageGranule = offset, granule
+		} else {
+			hi = offset
+		}
+	}
+
+	if err := sd.resyncAt(pageOffset); err != nil {
+		return err
+	}
+
+	// Discard samples up to the exact target, same as normal decoding but thrown away.
+	toDiscard := localTarget - pageGranule
+	if toDiscard < 0 {
+		toDiscard = 0
+	}
+	return sd.discardSamples(toDiscard)
+}
+
+// findPageAt scans forward from offset for the next complete Ogg page and
+// returns offset itself (the page starts at or after it), the page's
+// granulepos and its serial number.
+func (sd *SeekableDecoder) findPageAt(offset int64) (pageOffset, granule int64, serialno int32, err error) {
+	var syncState vorbis.OggSyncState
+	var page vorbis.OggPage
+	vorbis.OggSyncInit(&syncState)
+	defer func() {
+		vorbis.OggSyncDestroy(&syncState)
+		syncState.Free()
+		page.Free()
+	}()
+
+	if _, err = sd.source.Seek(offset, io.SeekStart); err != nil {
+		return 0, 0, 0, err
+	}
+
+	for {
+		buf := vorbis.OggSyncBuffer(&syncState, BISECT_CHUNK_SIZE)
+		n, rerr := io.ReadFull(sd.source, buf[:BISECT_CHUNK_SIZE])
+		vorbis.OggSyncWrote(&syncState, n)
+
+		if ret := vorbis.OggSyncPageout(&syncState, &page); ret == 1 {
+			return offset, vorbis.OggPageGranulepos(&page), vorbis.OggPageSerialno(&page), nil
+		}
+
+		if rerr != nil {
+			return 0, 0, 0, errors.New("vorbis: unable to locate an Ogg page while seeking")
+		}
+	}
+}
+
+// switchToLink re-initializes the Decoder's live streamState/info/comment for
+// a chained logical bitstream encountered mid-bisection, using the link's
+// byteOffset recorded by scanLinks (the exact start of its BOS/header page)
+// rather than the arbitrary offset the bisection probe landed on.
+func (sd *SeekableDecoder) switchToLink(serialno int32) error {
+	for i, l := range sd.links {
+		if l.serialno != serialno {
+			continue
+		}
+		sd.linkIndex = i
+		if _, err := sd.source.Seek(l.byteOffset, io.SeekStart); err != nil {
+			return err
+		}
+		vorbis.OggSyncReset(&sd.syncState)
+		return sd.readStreamHeaders(sd.source)
+	}
+	return errors.New("vorbis: encountered a chained bitstream that was not found during the initial scan")
+}
+
+// resyncAt reinitializes the decode pipeline at the first Ogg page found at
+// or after offset, mirroring the init sequence in Decoder.Decode.
+func (sd *SeekableDecoder) resyncAt(offset int64) error {
+	if _, err := sd.source.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	vorbis.OggSyncReset(&sd.syncState)
+	vorbis.OggStreamReset(&sd.streamState)
+
+	vorbis.DspClear(&sd.dspState)
+	vorbis.BlockClear(&sd.block)
+	if ret := vorbis.SynthesisInit(&sd.dspState, &sd.info); ret < 0 {
+		return errors.New("vorbis: error during playback re-initialization")
+	}
+	vorbis.BlockInit(&sd.dspState, &sd.block)
+	return nil
+}
+
+// discardSamples reads and throws away exactly n decoded samples, used to
+// land on the exact target sample after seeking to its containing page.
+func (sd *SeekableDecoder) discardSamples(n int64) error {
+	pcm := [][][]float32{
+		make([][]float32, sd.info.Channels),
+	}
+	for n > 0 {
+		if ret := vorbis.OggSyncPageout(&sd.syncState, &sd.page); ret <= 0 {
+			buf := vorbis.OggSyncBuffer(&sd.syncState, DATA_CHUNK_SIZE)
+			rn, err := io.ReadFull(sd.source, buf[:DATA_CHUNK_SIZE])
+			vorbis.OggSyncWrote(&sd.syncState, rn)
+			if err != nil && rn == 0 {
+				return nil
+			}
+			continue
+		}
+		vorbis.OggStreamPagein(&sd.streamState, &sd.page)
+
+		for n > 0 {
+			ret := vorbis.OggStreamPacketout(&sd.streamState, &sd.packet)
+			if ret <= 0 {
+				break
+			}
+			if vorbis.Synthesis(&sd.block, &sd.packet) == 0 {
+				vorbis.SynthesisBlockin(&sd.dspState, &sd.block)
+			}
+			for samples := vorbis.SynthesisPcmout(&sd.dspState, pcm); samples > 0; samples = vorbis.SynthesisPcmout(&sd.dspState, pcm) {
+				if int64(samples) > n {
+					samples = int32(n)
+				}
+				vorbis.SynthesisRead(&sd.dspState, samples)
+				n -= int64(samples)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+// Package encoder implements an OggVorbis encoder. Based on libogg/libvorbis bindings.
+package encoder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+
+	"github.com/xlab/vorbis-go/vorbis"
+)
+
+// Mode selects the bitrate management strategy used by the encoder.
+type Mode int
+
+const (
+	// ModeVBR requests variable bitrate encoding targeting a fixed quality.
+	ModeVBR Mode = iota
+	// ModeCBR requests managed constant bitrate encoding with explicit bitrates.
+	ModeCBR
+)
+
+// Config describes how a new Encoder should initialise the underlying Vorbis
+// encoder and what metadata should be embedded in the stream.
+type Config struct {
+	// Channels is the number of audio channels to encode.
+	Channels int32
+	// SampleRate is the input sample rate, in Hz.
+	SampleRate int64
+
+	// Mode selects between VBR and CBR encoding.
+	Mode Mode
+	// Quality is used in ModeVBR and ranges from 0.0 (lowest) to 1.0 (highest).
+	Quality float32
+	// MinBitrate, NominalBitrate and MaxBitrate are used in ModeCBR, in bits per second.
+	// A zero value for Min or Max leaves that bound unconstrained.
+	MinBitrate     int64
+	NominalBitrate int64
+	MaxBitrate     int64
+
+	// Vendor overrides the default Vorbis comment vendor string, if non-empty.
+	Vendor string
+	// Comments holds the Vorbis comment tags (e.g. "ARTIST", "TITLE") to embed
+	// in the stream.
+	Comments map[string]string
+}
+
+// Encoder implements an OggVorbis encoder that muxes encoded Vorbis packets
+// into a single logical Ogg bitstream written to an io.Writer.
+type Encoder struct {
+	sync.Mutex
+
+	// streamState tracks the state of the logical bitstream being written.
+	streamState vorbis.OggStreamState
+
+	// page encapsulates the data for an Ogg page read back from the stream state
+	// before being flushed to the output writer.
+	page vorbis.OggPage
+
+	// packet encapsulates a single raw packet of data handed from the Vorbis
+	// encoding engine to the Ogg framing layer.
+	packet vorbis.OggPacket
+
+	// info contains basic information about the audio to be encoded.
+	info vorbis.Info
+
+	// comment stores the Vorbis comment tags and vendor string to be embedded
+	// in the stream's header packets.
+	comment vorbis.Comment
+
+	// dspState is the state for one instance of the Vorbis encoder.
+	dspState vorbis.DspState
+
+	// block holds the data for a single block of audio being analysed.
+	// One Vorbis block translates to one codec packet.
+	block vorbis.Block
+
+	channels int32
+	output   io.Writer
+	closed   bool
+	wroteHdr bool
+}
+
+// New creates and initialises a new OggVorbis encoder that will write to w.
+func New(w io.Writer, cfg Config) (*Encoder, error) {
+	if cfg.Channels <= 0 {
+		return nil, errors.New("encoder: Channels must be positive")
+	}
+	if cfg.SampleRate <= 0 {
+		return nil, errors.New("encoder: SampleRate must be positive")
+	}
+
+	e := &Encoder{
+		channels: cfg.Channels,
+		output:   w,
+	}
+
+	vorbis.InfoInit(&e.info)
+
+	switch cfg.Mode {
+	case ModeCBR:
+		if ret := vorbis.EncodeInit(&e.info, cfg.Channels, cfg.SampleRate,
+			cfg.MaxBitrate, cfg.NominalBitrate, cfg.MinBitrate); ret < 0 {
+			vorbis.InfoClear(&e.info)
+			return nil, fmt.Errorf("vorbis: unable to init CBR encoder: %d", ret)
+		}
+	default:
+		if ret := vorbis.EncodeInitVbr(&e.info, cfg.Channels, cfg.SampleRate, cfg.Quality); ret < 0 {
+			vorbis.InfoClear(&e.info)
+			return nil, fmt.Errorf("vorbis: unable to init VBR encoder: %d", ret)
+		}
+	}
+
+	vorbis.CommentInit(&e.comment)
+	if cfg.Vendor != "" {
+		vorbis.CommentAddVendor(&e.comment, cfg.Vendor)
+	}
+	for key, value := range cfg.Comments {
+		vorbis.CommentAddTag(&e.comment, key, value)
+	}
+
+	if ret := vorbis.AnalysisInit(&e.dspState, &e.info); ret < 0 {
+		e.cleanupInfoComment()
+		return nil, errors.New("vorbis: error during analysis initialization")
+	}
+	vorbis.BlockInit(&e.dspState, &e.block)
+
+	vorbis.OggStreamInit(&e.streamState, rand.Int31())
+
+	if err := e.writeHeaders(); err != nil {
+		e.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *Encoder) cleanupInfoComment() {
+	vorbis.CommentClear(&e.comment)
+	e.comment.Free()
+	vorbis.InfoClear(&e.info)
+	e.info.Free()
+}
+
+// writeHeaders emits the three Vorbis header packets (identification, comment
+// and setup) as the first Ogg page(s) of the stream.
+func (e *Encoder) writeHeaders() error {
+	var idHeader, commentHeader, codeHeader vorbis.OggPacket
+	if ret := vorbis.AnalysisHeaderout(&e.dspState, &e.comment, &idHeader, &commentHeader, &codeHeader); ret < 0 {
+		return fmt.Errorf("vorbis: unable to build header packets: %d", ret)
+	}
+
+	vorbis.OggStreamPacketin(&e.streamState, &idHeader)
+	vorbis.OggStreamPacketin(&e.streamState, &commentHeader)
+	vorbis.OggStreamPacketin(&e.streamState, &codeHeader)
+
+	// Flush so the headers land on their own page(s), ahead of any audio data.
+	for vorbis.OggStreamFlush(&e.streamState, &e.page) != 0 {
+		if err := e.writePage(); err != nil {
+			return err
+		}
+	}
+	e.wroteHdr = true
+	return nil
+}
+
+// Write encodes one frame of interleaved-by-sample PCM data and muxes any
+// resulting Vorbis packets into the Ogg stream. frame must have the shape
+// returned by decoder.Decoder.SamplesOut(), i.e. frame[sampleIndex][channel].
+func (e *Encoder) Write(frame [][]float32) error {
+	e.Lock()
+	defer e.Unlock()
+	if e.closed {
+		return errors.New("encoder: encoder has already been closed")
+	}
+	return e.submit(frame)
+}
+
+func (e *Encoder) submit(frame [][]float32) error {
+	n := len(frame)
+	pcm := vorbis.AnalysisBuffer(&e.dspState, n)
+	for i, sample := range frame {
+		for ch := int32(0); ch < e.channels; ch++ {
+			pcm[ch][i] = sample[ch]
+		}
+	}
+	vorbis.AnalysisWrote(&e.dspState, n)
+	return e.drain()
+}
+
+// drain pulls every Vorbis block and Ogg page currently available out of the
+// encoding pipeline and writes the resulting pages to the output.
+func (e *Encoder) drain() error {
+	for vorbis.AnalysisBlockout(&e.dspState, &e.block) == 1 {
+		if ret := vorbis.Analysis(&e.block, nil); ret < 0 {
+			return fmt.Errorf("vorbis: error during analysis: %d", ret)
+		}
+		vorbis.BitrateAddblock(&e.block)
+
+		for vorbis.BitrateFlushpacket(&e.dspState, &e.packet) != 0 {
+			vorbis.OggStreamPacketin(&e.streamState, &e.packet)
+
+			for vorbis.OggStreamPageout(&e.streamState, &e.page) != 0 {
+				if err := e.writePage(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) writePage() error {
+	if _, err := e.output.Write(e.page.Header[:e.page.HeaderLen]); err != nil {
+		return err
+	}
+	_, err := e.output.Write(e.page.Body[:e.page.BodyLen])
+	return err
+}
+
+// Close signals end-of-stream, flushes any remaining audio and Ogg pages, and
+// releases the resources held by the encoder. Puts the encoder into an
+// unrecoverable state.
+func (e *Encoder) Close() error {
+	e.Lock()
+	defer e.Unlock()
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	var err error
+	if e.wroteHdr {
+		// A zero-length vorbis_analysis_wrote call signals end-of-stream.
+		vorbis.AnalysisWrote(&e.dspState, 0)
+		err = e.drain()
+		for vorbis.OggStreamFlush(&e.streamState, &e.page) != 0 {
+			if werr := e.writePage(); werr != nil && err == nil {
+				err = werr
+			}
+		}
+	}
+
+	vorbis.OggStreamClear(&e.streamState)
+	e.streamState.Free()
+	vorbis.BlockClear(&e.block)
+	vorbis.DspClear(&e.dspState)
+	e.cleanupInfoComment()
+	e.packet.Free()
+	e.page.Free()
+
+	return err
+}
@@ -0,0 +1,272 @@
+// Package streamer implements a live Icecast/Shoutcast source client built on
+// top of the sibling encoder package, giving a Go-native alternative to
+// ices/ezstream for pushing a Vorbis stream to a streaming server.
+package streamer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/xlab/vorbis-go/encoder"
+)
+
+const (
+	// FRAME_BUFFER_SIZE defines how many pending PCM frames are buffered
+	// between the producer goroutine and the network writer goroutine.
+	FRAME_BUFFER_SIZE = 32
+	// reconnectDelay is how long run waits before retrying a dropped connection.
+	reconnectDelay = 2 * time.Second
+)
+
+// Mount describes the Icecast mountpoint a Streamer publishes to and the
+// metadata advertised for it.
+type Mount struct {
+	// Mountpoint is the path component of the mount, e.g. "/stream.ogg".
+	Mountpoint string
+	// ContentType is advertised to the server, normally "application/ogg".
+	ContentType string
+	// BitrateKbps is advertised to listing directories (informational only).
+	BitrateKbps int
+	// Name, Genre and Description populate the server's stream directory entry.
+	Name        string
+	Genre       string
+	Description string
+	// Public marks the stream for inclusion in the server's public directory.
+	Public bool
+}
+
+// Config configures a Streamer's connection to the Icecast server and the
+// encoder used to produce the Vorbis stream.
+type Config struct {
+	// ServerURL is the base URL of the Icecast server, e.g. "http://localhost:8000".
+	ServerURL string
+	// Username and Password authenticate against the mountpoint (source credentials).
+	Username string
+	Password string
+
+	Mount Mount
+
+	// Encoder configures the underlying Vorbis encoder used for every logical
+	// bitstream the Streamer produces.
+	Encoder encoder.Config
+
+	// OnError, if set, is called with non-fatal errors encountered while
+	// streaming (e.g. a dropped connection about to be retried).
+	OnError func(err error)
+}
+
+// Streamer connects an encoder to a live Icecast mountpoint over HTTP PUT,
+// handling reconnection and exposing a channel-based API for feeding PCM
+// frames without blocking the producer on network I/O.
+type Streamer struct {
+	sync.Mutex
+
+	cfg Config
+
+	frames   chan [][]float32
+	stopChan chan struct{}
+	doneChan chan struct{}
+	closed   bool
+	closeErr error
+
+	pipeW *io.PipeWriter
+	enc   *encoder.Encoder
+}
+
+// New creates a Streamer for the given configuration and starts connecting
+// to the Icecast server in the background. Use WriteFrame to push PCM frames
+// and UpdateMetadata to change the advertised title/artist mid-stream.
+func New(cfg Config) (*Streamer, error) {
+	if cfg.ServerURL == "" {
+		return nil, errors.New("streamer: ServerURL must be set")
+	}
+	if cfg.Mount.Mountpoint == "" {
+		return nil, errors.New("streamer: Mount.Mountpoint must be set")
+	}
+	if cfg.Mount.ContentType == "" {
+		cfg.Mount.ContentType = "application/ogg"
+	}
+
+	s := &Streamer{
+		cfg:      cfg,
+		frames:   make(chan [][]float32, FRAME_BUFFER_SIZE),
+		stopChan: make(chan struct{}),
+		doneChan: make(chan struct{}),
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// WriteFrame queues a PCM frame for encoding and transmission. It blocks only
+// when the internal buffer between the producer and the network writer is
+// full, providing backpressure without stalling on network I/O directly.
+func (s *Streamer) WriteFrame(frame [][]float32) error {
+	select {
+	case <-s.stopChan:
+		return errors.New("streamer: streamer has been closed")
+	case s.frames <- frame:
+		return nil
+	}
+}
+
+// UpdateMetadata starts a new chained logical Vorbis bitstream carrying the
+// updated comment tags (e.g. "TITLE", "ARTIST"), matching the way reencoders
+// begin a new stream at a critical/EOS boundary when metadata changes.
+func (s *Streamer) UpdateMetadata(tags map[string]string) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.closed || s.enc == nil {
+		return errors.New("streamer: not connected")
+	}
+
+	if err := s.enc.Close(); err != nil {
+		s.reportError(fmt.Errorf("streamer: error closing chained stream: %w", err))
+	}
+
+	cfg := s.cfg.Encoder
+	cfg.Comments = tags
+	enc, err := encoder.New(s.pipeW, cfg)
+	if err != nil {
+		return err
+	}
+	s.enc = enc
+	return nil
+}
+
+// Close stops the streamer and waits for the connection goroutine to flush
+// the current encoder (sending EOS) and tear down the connection to the
+// server. The flush happens on the connection goroutine itself, so it cannot
+// race a concurrent close of the same pipe.
+func (s *Streamer) Close() error {
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.Unlock()
+
+	close(s.stopChan)
+	<-s.doneChan
+
+	s.Lock()
+	defer s.Unlock()
+	return s.closeErr
+}
+
+func (s *Streamer) reportError(err error) {
+	if s.cfg.OnError != nil {
+		s.cfg.OnError(err)
+	}
+}
+
+// run drives the connect/reconnect loop, restarting the HTTP PUT and a fresh
+// logical bitstream whenever the connection to the server drops.
+func (s *Streamer) run() {
+	defer close(s.doneChan)
+
+	for {
+		err := s.connectAndStream()
+
+		select {
+		case <-s.stopChan:
+			s.Lock()
+			s.closeErr = err
+			s.Unlock()
+			return
+		default:
+		}
+
+		if err != nil {
+			s.reportError(fmt.Errorf("streamer: connection lost: %w", err))
+		}
+
+		select {
+		case <-s.stopChan:
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (s *Streamer) connectAndStream() error {
+	pr, pw := io.Pipe()
+
+	req, err := http.NewRequest(http.MethodPut, s.cfg.ServerURL+s.cfg.Mount.Mountpoint, pr)
+	if err != nil {
+		pw.Close()
+		return err
+	}
+	req.Header.Set("Content-Type", s.cfg.Mount.ContentType)
+	req.Header.Set("ice-name", s.cfg.Mount.Name)
+	req.Header.Set("ice-genre", s.cfg.Mount.Genre)
+	req.Header.Set("ice-description", s.cfg.Mount.Description)
+	req.Header.Set("ice-bitrate", fmt.Sprintf("%d", s.cfg.Mount.BitrateKbps))
+	if s.cfg.Mount.Public {
+		req.Header.Set("ice-public", "1")
+	} else {
+		req.Header.Set("ice-public", "0")
+	}
+	if s.cfg.Username != "" {
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+
+	// Start reading the request body before encoder.New writes the header
+	// pages to pw: io.Pipe writes block until a matching read happens, so
+	// encoder.New would otherwise deadlock waiting for a reader that only
+	// Do(req) ever provides.
+	respErr := make(chan error, 1)
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			respErr <- err
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			respErr <- fmt.Errorf("streamer: server responded with status %s", resp.Status)
+			return
+		}
+		respErr <- nil
+	}()
+
+	enc, err := encoder.New(pw, s.cfg.Encoder)
+	if err != nil {
+		pw.Close()
+		return err
+	}
+
+	s.Lock()
+	s.pipeW = pw
+	s.enc = enc
+	s.Unlock()
+
+	for {
+		select {
+		case <-s.stopChan:
+			// Flush and send EOS on this goroutine before closing pw, so
+			// Close can't race the pipe shut before the final page is read.
+			s.Lock()
+			err := s.enc.Close()
+			s.Unlock()
+			pw.Close()
+			return err
+		case err := <-respErr:
+			pw.Close()
+			return err
+		case frame := <-s.frames:
+			s.Lock()
+			werr := s.enc.Write(frame)
+			s.Unlock()
+			if werr != nil {
+				pw.Close()
+				return werr
+			}
+		}
+	}
+}